@@ -4,28 +4,62 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
 	"time"
 )
 
 const usage = `
-read list of hosts from file and report servers listening on ports 80 or 443.
+read list of hosts from file and report which of their ports are listening.
 usage: webcheck FILE
 `
 
+var (
+	parallel    = flag.Int("parallel", 20, "maximum number of hosts to check concurrently")
+	dnsTimeout  = flag.Duration("dns-timeout", 5*time.Second, "timeout for resolving a host name")
+	dialTimeout = flag.Duration("dial-timeout", 3*time.Second, "timeout for dialing and probing a single port")
+	format      = flag.String("format", "text", `output format: "text", "json", or "csv"`)
+	portsFlag   = flag.String("ports", "80/http,443/https", `comma-separated ports to probe, each optionally annotated with a scheme (e.g. "80,443,8080/http,8443/https")`)
+	watch       = flag.Duration("watch", 0, "if set, repeat the sweep on this interval and report only what changed, as JSON events")
+	certWarn    = flag.Duration("cert-expiry-warning", 14*24*time.Hour, "in -watch mode, emit a cert_expiring event once a certificate's expiry falls within this duration")
+	netFlag     = flag.String("net", "ip", `IP network to resolve and probe: "ip", "ip4", or "ip6" (mirrors net.Dial's network suffix)`)
+)
+
 // hostInfo contains the data result of checking a host. It satisfies the
 // Stringer interface.
 type hostInfo struct {
-	name  string
-	addrs []string
-	ports []string
-	err   error
+	name    string
+	addrs   []string
+	results []*addrPortResult
+	err     error
+}
+
+// addrPortResult is the probe outcome for one (address, port) pair. Dual-
+// stack hosts can be up on one address family and down on another, so each
+// pair gets its own result rather than being collapsed into a single
+// host-level answer.
+type addrPortResult struct {
+	addr string
+	Port
+}
+
+// certInfo summarizes a peer certificate seen during a TLS probe.
+type certInfo struct {
+	subject  string
+	issuer   string
+	notAfter time.Time
+	sans     []string
 }
 
 func (i *hostInfo) String() string {
@@ -36,20 +70,61 @@ func (i *hostInfo) String() string {
 		)
 	}
 
-	var status, portsInfo string
-	if len(i.ports) != 0 {
+	var status string
+	if anyOpen(i.results) {
 		status = "server is up"
-		portsInfo = strings.Join(i.ports, ", ")
 	} else {
 		status = "server may be down"
-		portsInfo = "no known HTTP(S) ports listening"
 	}
 
 	addrsInfo := strings.Join(i.addrs, ", ")
-	return fmt.Sprintf(
-		"name:\t%v\nips:\t%v\nstatus:\t%v\nports:\t%v\n",
-		i.name, addrsInfo, status, portsInfo,
-	)
+	var b strings.Builder
+	fmt.Fprintf(&b, "name:\t%v\nips:\t%v\nstatus:\t%v\n", i.name, addrsInfo, status)
+
+	if len(i.results) == 0 {
+		fmt.Fprintf(&b, "ports:\tno known HTTP(S) ports listening\n")
+		return b.String()
+	}
+	for _, r := range i.results {
+		openOrClosed := "closed"
+		if r.open {
+			openOrClosed = "open"
+		}
+		fmt.Fprintf(&b, "%s port %s/%s:\t%s\n", r.addr, r.number, r.scheme, openOrClosed)
+		if !r.open {
+			continue
+		}
+		if r.statusCode != 0 {
+			fmt.Fprintf(&b, "\thttp status:\t%d\n", r.statusCode)
+			if r.server != "" {
+				fmt.Fprintf(&b, "\tserver:\t%s\n", r.server)
+			}
+		}
+		if r.tlsVersion != "" {
+			fmt.Fprintf(&b, "\ttls version:\t%s\n", r.tlsVersion)
+			if r.alpn != "" {
+				fmt.Fprintf(&b, "\talpn:\t%s\n", r.alpn)
+			}
+			for _, c := range r.certs {
+				fmt.Fprintf(&b, "\tcert:\tsubject=%q issuer=%q not_after=%s sans=%v\n",
+					c.subject, c.issuer, c.notAfter.Format(time.RFC3339), c.sans)
+			}
+			if r.trustError != "" {
+				fmt.Fprintf(&b, "\tcert trust:\tFAILED: %s\n", r.trustError)
+			}
+		}
+	}
+	return b.String()
+}
+
+// anyOpen reports whether any result in results is open.
+func anyOpen(results []*addrPortResult) bool {
+	for _, r := range results {
+		if r.open {
+			return true
+		}
+	}
+	return false
 }
 
 func main() {
@@ -59,6 +134,9 @@ func main() {
 	if infile == "" {
 		log.Fatal("missing required file argument")
 	}
+	if *parallel <= 0 {
+		log.Fatal("-parallel must be positive")
+	}
 
 	hosts, err := parseInfile(infile)
 	if err != nil {
@@ -68,8 +146,40 @@ func main() {
 		log.Fatal("empty hosts file")
 	}
 
-	for r := range resolveAll(hosts) {
-		fmt.Println(r)
+	ports, err := parsePorts(*portsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *netFlag {
+	case "ip", "ip4", "ip6":
+	default:
+		log.Fatalf("-net must be \"ip\", \"ip4\", or \"ip6\", got %q", *netFlag)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *watch > 0 {
+		err := runWatch(ctx, hosts, ports, *netFlag, *parallel, *dnsTimeout, *dialTimeout, *watch, *certWarn, os.Stdout)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	reporter, err := newReporter(*format, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for r := range resolveAll(ctx, hosts, ports, *netFlag, *parallel, *dnsTimeout, *dialTimeout) {
+		if err := reporter.Report(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := reporter.Close(); err != nil {
+		log.Fatal(err)
 	}
 }
 
@@ -103,20 +213,27 @@ func parseInfile(file string) ([]string, error) {
 
 // Attempt to resolve each hostname and reach each host.
 //
+// At most parallel hosts are checked concurrently, via a counting semaphore;
+// this keeps the program from exhausting file descriptors and DNS resolver
+// capacity on large host lists. Canceling ctx (e.g. via Ctrl-C) abandons any
+// outstanding lookups and dials.
+//
 // This returns a channel from which to read results; when all checks finish,
 // the channel is closed.
-func resolveAll(hosts []string) <-chan *hostInfo {
-	const timeout = time.Second * 5
+func resolveAll(ctx context.Context, hosts []string, ports []Port, network string, parallel int, dnsTimeout, dialTimeout time.Duration) <-chan *hostInfo {
 	rc := make(chan *hostInfo, 1<<10)
+	sem := make(chan struct{}, parallel)
 
 	go func() {
 		var wg sync.WaitGroup
 		for _, h := range hosts {
 			h := h
 			wg.Add(1)
+			sem <- struct{}{}
 			go func() {
-				rc <- resolveOne(h)
-				wg.Done()
+				defer wg.Done()
+				defer func() { <-sem }()
+				rc <- resolveOne(ctx, h, ports, network, dnsTimeout, dialTimeout)
 			}()
 		}
 		wg.Wait()
@@ -126,37 +243,171 @@ func resolveAll(hosts []string) <-chan *hostInfo {
 }
 
 // Attempt to resolve a single host and return a *hostInfo as the result.
-func resolveOne(host string) *hostInfo {
+//
+// network is passed straight to the resolver and dialer to restrict which
+// address family ("ip", "ip4", or "ip6") is looked up and probed.
+func resolveOne(ctx context.Context, host string, ports []Port, network string, dnsTimeout, dialTimeout time.Duration) *hostInfo {
 	hi := &hostInfo{name: host}
-	if hi.addrs, hi.err = net.LookupHost(host); hi.err != nil {
+
+	lookupCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	var resolver net.Resolver
+	ips, err := resolver.LookupIP(lookupCtx, network, host)
+	if err != nil {
+		hi.err = err
 		return hi
 	}
+	for _, ip := range ips {
+		hi.addrs = append(hi.addrs, ip.String())
+	}
 
-	var (
-		ports   = map[string]bool{"80": false, "443": false}
-		timeout = time.Second * 3
-	)
 	for _, a := range hi.addrs {
-		for p := range ports {
-			conn, err := net.DialTimeout("tcp", a+":"+p, timeout)
-			if err != nil {
-				continue
-			}
-			conn.Close()
-			ports[p] = true
+		for _, p := range ports {
+			r := &addrPortResult{addr: a, Port: p}
+			probePort(ctx, host, r, dialTimeout)
+			hi.results = append(hi.results, r)
 		}
 	}
+	return hi
+}
+
+// probePort dials r's address and port and, if it answers, performs an
+// application-layer probe appropriate to r's scheme: an HTTP HEAD request,
+// or a TLS handshake.
+func probePort(ctx context.Context, host string, r *addrPortResult, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	// De-dupe ports before adding them to hostInfo.
-	//
-	// This is an antipattern: hostInfo should really have methods to do
-	// his itself so that this function doesn't muck around with internal
-	// details. But this is just a slapdash golang demo for a friend, so
-	// I won't tell if you won't.
-	for p := range ports {
-		if ports[p] {
-			hi.ports = append(hi.ports, p)
+	addr := net.JoinHostPort(r.addr, r.number)
+	switch r.scheme {
+	case "https":
+		info, err := probeTLS(ctx, host, addr)
+		if err != nil {
+			return
 		}
+		r.open = true
+		r.tlsVersion = info.version
+		r.alpn = info.alpn
+		r.certs = info.certs
+		r.trustError = info.trustError
+	default:
+		status, server, err := probeHTTP(ctx, host, addr)
+		if err != nil {
+			return
+		}
+		r.open = true
+		r.statusCode = status
+		r.server = server
+	}
+}
+
+// probeHTTP sends a bare "HEAD /" HTTP/1.1 request to addr, using host as the
+// Host header, and returns the response status code and Server header.
+func probeHTTP(ctx context.Context, host, addr string) (status int, server string, err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return 0, "", err
+		}
+	}
+	req := "HEAD / HTTP/1.1\r\nHost: " + host + "\r\nConnection: close\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return 0, "", err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Server"), nil
+}
+
+// tlsProbeInfo is the result of a successful TLS handshake probe.
+type tlsProbeInfo struct {
+	version    string
+	alpn       string
+	certs      []certInfo
+	trustError string // non-empty if the peer's certificate chain failed trust verification
+}
+
+// probeTLS completes a TLS handshake against addr with SNI set to host and
+// ALPN advertising h2 and http/1.1, and summarizes the negotiated
+// connection state and peer certificate chain.
+//
+// The handshake skips certificate verification so that self-signed or
+// expired certificates - exactly the servers a fingerprinting tool is most
+// interested in - don't get reported as unreachable. Trust is checked
+// afterward, out-of-band, and any failure is surfaced as trustError rather
+// than as a probe error.
+func probeTLS(ctx context.Context, host, addr string) (*tlsProbeInfo, error) {
+	dialer := tls.Dialer{Config: &tls.Config{
+		ServerName:         host,
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	info := &tlsProbeInfo{
+		version: tlsVersionName(state.Version),
+		alpn:    state.NegotiatedProtocol,
+	}
+	for _, c := range state.PeerCertificates {
+		info.certs = append(info.certs, certInfo{
+			subject:  c.Subject.String(),
+			issuer:   c.Issuer.String(),
+			notAfter: c.NotAfter,
+			sans:     c.DNSNames,
+		})
+	}
+	if len(state.PeerCertificates) != 0 {
+		if err := verifyTrust(host, state.PeerCertificates); err != nil {
+			info.trustError = err.Error()
+		}
+	}
+	return info, nil
+}
+
+// verifyTrust checks the presented certificate chain against the system
+// root store and host, the way a TLS client would during a normal
+// handshake.
+func verifyTrust(host string, chain []*x509.Certificate) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// tlsVersionName returns the human-readable name of a tls.VersionXXX
+// constant, or a hex fallback for anything unrecognized.
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", v)
 	}
-	return hi
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Port is a single port to probe on a host, along with the scheme to speak
+// on it (plain HTTP or TLS) and, once probed, the result.
+type Port struct {
+	number string
+	scheme string // "http" or "https"
+
+	open       bool
+	statusCode int    // HTTP status code, set if an HTTP probe was performed.
+	server     string // Server header, set if present.
+	tlsVersion string // negotiated TLS version name, set if a TLS probe was performed.
+	alpn       string // negotiated ALPN protocol, set if any was agreed.
+	certs      []certInfo
+	trustError string // non-empty if the peer's certificate chain failed trust verification
+}
+
+// parsePorts parses a comma-separated port list such as "80,443,8080/http"
+// into a slice of Ports. Each entry may carry an explicit "/http" or
+// "/https" scheme annotation; without one, 443 defaults to https and every
+// other port defaults to http.
+func parsePorts(s string) ([]Port, error) {
+	var ports []Port
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		number, scheme, hasScheme := strings.Cut(field, "/")
+		if _, err := strconv.Atoi(number); err != nil {
+			return nil, fmt.Errorf("port %q: invalid port number", field)
+		}
+		if !hasScheme {
+			scheme = "http"
+			if number == "443" {
+				scheme = "https"
+			}
+		}
+		if scheme != "http" && scheme != "https" {
+			return nil, fmt.Errorf("port %q: scheme must be http or https", field)
+		}
+		ports = append(ports, Port{number: number, scheme: scheme})
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("port list must not be empty")
+	}
+	return ports, nil
+}
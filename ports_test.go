@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []Port
+		wantErr bool
+	}{
+		{
+			name: "default scheme inference",
+			in:   "80,443",
+			want: []Port{{number: "80", scheme: "http"}, {number: "443", scheme: "https"}},
+		},
+		{
+			name: "explicit scheme annotations",
+			in:   "8080/http,8443/https",
+			want: []Port{{number: "8080", scheme: "http"}, {number: "8443", scheme: "https"}},
+		},
+		{
+			name: "explicit scheme overrides the 443 default",
+			in:   "443/http",
+			want: []Port{{number: "443", scheme: "http"}},
+		},
+		{
+			name: "whitespace and blank fields are ignored",
+			in:   " 80 , , 443 ",
+			want: []Port{{number: "80", scheme: "http"}, {number: "443", scheme: "https"}},
+		},
+		{
+			name:    "invalid port number",
+			in:      "abc",
+			wantErr: true,
+		},
+		{
+			name:    "unknown scheme",
+			in:      "8080/ftp",
+			wantErr: true,
+		},
+		{
+			name:    "empty list",
+			in:      "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePorts(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePorts(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePorts(%q) returned unexpected error: %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePorts(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reporter writes hostInfo results to an output stream in some format.
+// Report is called once per host, in the order results arrive; Close is
+// called once after the last Report call to flush any buffered output.
+type Reporter interface {
+	Report(*hostInfo) error
+	Close() error
+}
+
+// newReporter returns a Reporter that writes to w in the named format:
+// "text", "json", or "csv". An unrecognized format is an error.
+func newReporter(format string, w io.Writer) (Reporter, error) {
+	switch format {
+	case "text":
+		return &textReporter{w: w}, nil
+	case "json":
+		return &jsonReporter{enc: json.NewEncoder(w)}, nil
+	case "csv":
+		return newCSVReporter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Record is the stable, documented representation of a single host's check
+// result, used by the JSON and CSV reporters. Field names and meanings will
+// not change across releases; new fields may be added.
+type Record struct {
+	Host    string         `json:"host"`
+	Addrs   []string       `json:"addrs,omitempty"`
+	Up      bool           `json:"up"`
+	Error   string         `json:"error,omitempty"`
+	Results []ResultRecord `json:"results,omitempty"`
+}
+
+// ResultRecord is the detail for a single (address, port) pair, nested
+// inside a Record.
+type ResultRecord struct {
+	Addr       string       `json:"addr"`
+	Port       string       `json:"port"`
+	Scheme     string       `json:"scheme"`
+	Open       bool         `json:"open"`
+	StatusCode int          `json:"status_code,omitempty"`
+	Server     string       `json:"server,omitempty"`
+	TLSVersion string       `json:"tls_version,omitempty"`
+	ALPN       string       `json:"alpn,omitempty"`
+	Certs      []CertRecord `json:"certs,omitempty"`
+	TrustError string       `json:"trust_error,omitempty"`
+}
+
+// CertRecord is the per-certificate detail nested inside a ResultRecord.
+type CertRecord struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	SANs     []string  `json:"sans,omitempty"`
+}
+
+// record converts i to its stable Record representation.
+func (i *hostInfo) record() Record {
+	rec := Record{
+		Host:  i.name,
+		Addrs: i.addrs,
+	}
+	if i.err != nil {
+		rec.Error = i.err.Error()
+	}
+	for _, r := range i.results {
+		if r.open {
+			rec.Up = true
+		}
+		rr := ResultRecord{
+			Addr:       r.addr,
+			Port:       r.number,
+			Scheme:     r.scheme,
+			Open:       r.open,
+			StatusCode: r.statusCode,
+			Server:     r.server,
+			TLSVersion: r.tlsVersion,
+			ALPN:       r.alpn,
+			TrustError: r.trustError,
+		}
+		for _, c := range r.certs {
+			rr.Certs = append(rr.Certs, CertRecord{
+				Subject:  c.subject,
+				Issuer:   c.issuer,
+				NotAfter: c.notAfter,
+				SANs:     c.sans,
+			})
+		}
+		rec.Results = append(rec.Results, rr)
+	}
+	return rec
+}
+
+// textReporter writes the existing human-readable, tab-aligned report.
+type textReporter struct {
+	w io.Writer
+}
+
+func (r *textReporter) Report(hi *hostInfo) error {
+	_, err := fmt.Fprintln(r.w, hi)
+	return err
+}
+
+func (r *textReporter) Close() error { return nil }
+
+// jsonReporter writes one JSON object per host, newline-delimited, suitable
+// for piping into jq or a log aggregator.
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+func (r *jsonReporter) Report(hi *hostInfo) error {
+	return r.enc.Encode(hi.record())
+}
+
+func (r *jsonReporter) Close() error { return nil }
+
+// csvReporter writes one row per (host, address, port) tuple. Hosts that
+// failed to resolve, and so have no results, still get a single row with
+// the address/port columns left blank.
+type csvReporter struct {
+	w   *csv.Writer
+	hdr bool
+}
+
+var csvHeader = []string{
+	"host", "addrs", "up", "error",
+	"addr", "port", "scheme", "open", "status_code", "server", "tls_version", "alpn", "not_after", "trust_error",
+}
+
+func newCSVReporter(w io.Writer) *csvReporter {
+	return &csvReporter{w: csv.NewWriter(w)}
+}
+
+func (r *csvReporter) Report(hi *hostInfo) error {
+	if !r.hdr {
+		if err := r.w.Write(csvHeader); err != nil {
+			return err
+		}
+		r.hdr = true
+	}
+
+	rec := hi.record()
+	common := []string{
+		rec.Host,
+		strings.Join(rec.Addrs, ";"),
+		strconv.FormatBool(rec.Up),
+		rec.Error,
+	}
+	if len(rec.Results) == 0 {
+		row := append(common, make([]string, len(csvHeader)-len(common))...)
+		return r.w.Write(row)
+	}
+	for _, res := range rec.Results {
+		var notAfter string
+		if len(res.Certs) != 0 {
+			notAfter = res.Certs[0].NotAfter.Format(time.RFC3339)
+		}
+		row := append([]string{}, common...)
+		row = append(row,
+			res.Addr,
+			res.Port,
+			res.Scheme,
+			strconv.FormatBool(res.Open),
+			statusCodeOrEmpty(res.StatusCode),
+			res.Server,
+			res.TLSVersion,
+			res.ALPN,
+			notAfter,
+			res.TrustError,
+		)
+		if err := r.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *csvReporter) Close() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+func statusCodeOrEmpty(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a structured record of a single change observed between two
+// watch cycles, emitted as newline-delimited JSON.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	Type   string    `json:"type"` // "up", "down", "addrs_changed", or "cert_expiring"
+	Detail string    `json:"detail,omitempty"`
+	Record Record    `json:"record"`
+}
+
+// runWatch re-runs the full host sweep on interval until ctx is canceled,
+// comparing each cycle's results against the previous cycle's and emitting
+// an Event for every host that transitioned up, down, changed its resolved
+// IP set, or whose certificate expiry crossed certWarn. The first cycle
+// only establishes the baseline; nothing is emitted for it.
+func runWatch(ctx context.Context, hosts []string, ports []Port, network string, parallel int, dnsTimeout, dialTimeout, interval, certWarn time.Duration, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := map[string]Record{}
+	for {
+		curr := map[string]Record{}
+		for hi := range resolveAll(ctx, hosts, ports, network, parallel, dnsTimeout, dialTimeout) {
+			curr[hi.name] = hi.record()
+		}
+
+		now := time.Now()
+		for host, c := range curr {
+			if p, ok := prev[host]; ok {
+				for _, ev := range diffRecords(p, c, now, certWarn) {
+					if err := enc.Encode(ev); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		prev = curr
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// diffRecords compares a host's previous and current Record and returns an
+// Event for every change worth reporting.
+func diffRecords(prev, curr Record, now time.Time, certWarn time.Duration) []Event {
+	var events []Event
+
+	switch {
+	case !prev.Up && curr.Up:
+		events = append(events, newEvent(now, curr, "up", ""))
+	case prev.Up && !curr.Up:
+		events = append(events, newEvent(now, curr, "down", ""))
+	}
+
+	if prev.Up && curr.Up && !sameAddrs(prev.Addrs, curr.Addrs) {
+		detail := fmt.Sprintf("%s -> %s", strings.Join(prev.Addrs, ";"), strings.Join(curr.Addrs, ";"))
+		events = append(events, newEvent(now, curr, "addrs_changed", detail))
+	}
+
+	prevExpiring := expiringResults(prev, now, certWarn)
+	currExpiring := expiringResults(curr, now, certWarn)
+	for key, isExpiring := range currExpiring {
+		if isExpiring && !prevExpiring[key] {
+			detail := fmt.Sprintf("%s certificate expires within %s", key, certWarn)
+			events = append(events, newEvent(now, curr, "cert_expiring", detail))
+		}
+	}
+
+	return events
+}
+
+func newEvent(now time.Time, rec Record, typ, detail string) Event {
+	return Event{Time: now, Host: rec.Host, Type: typ, Detail: detail, Record: rec}
+}
+
+// sameAddrs reports whether two address lists contain the same elements,
+// regardless of order.
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expiringResults returns the set of "addr:port" keys in rec whose leaf
+// certificate expires within certWarn of now.
+func expiringResults(rec Record, now time.Time, certWarn time.Duration) map[string]bool {
+	expiring := make(map[string]bool)
+	for _, r := range rec.Results {
+		if len(r.Certs) == 0 {
+			continue
+		}
+		key := r.Addr + ":" + r.Port
+		expiring[key] = r.Certs[0].NotAfter.Before(now.Add(certWarn))
+	}
+	return expiring
+}
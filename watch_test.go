@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffRecordsUpDown(t *testing.T) {
+	now := time.Now()
+	down := Record{Host: "a", Up: false}
+	up := Record{Host: "a", Up: true, Results: []ResultRecord{{Addr: "1.2.3.4", Port: "80", Open: true}}}
+
+	events := diffRecords(down, up, now, time.Hour)
+	if len(events) != 1 || events[0].Type != "up" {
+		t.Fatalf("down->up: got %+v, want a single \"up\" event", events)
+	}
+
+	events = diffRecords(up, down, now, time.Hour)
+	if len(events) != 1 || events[0].Type != "down" {
+		t.Fatalf("up->down: got %+v, want a single \"down\" event", events)
+	}
+
+	events = diffRecords(up, up, now, time.Hour)
+	if len(events) != 0 {
+		t.Fatalf("no change: got %+v, want no events", events)
+	}
+}
+
+func TestDiffRecordsAddrsChanged(t *testing.T) {
+	now := time.Now()
+	prev := Record{Host: "a", Up: true, Addrs: []string{"1.1.1.1"}}
+	curr := Record{Host: "a", Up: true, Addrs: []string{"2.2.2.2"}}
+
+	events := diffRecords(prev, curr, now, time.Hour)
+	if len(events) != 1 || events[0].Type != "addrs_changed" {
+		t.Fatalf("got %+v, want a single \"addrs_changed\" event", events)
+	}
+
+	// Reordering the same set of addresses is not a change.
+	prev = Record{Host: "a", Up: true, Addrs: []string{"1.1.1.1", "2.2.2.2"}}
+	curr = Record{Host: "a", Up: true, Addrs: []string{"2.2.2.2", "1.1.1.1"}}
+	events = diffRecords(prev, curr, now, time.Hour)
+	if len(events) != 0 {
+		t.Fatalf("reordered addrs: got %+v, want no events", events)
+	}
+}
+
+func TestDiffRecordsCertExpiring(t *testing.T) {
+	now := time.Now()
+	certWarn := 14 * 24 * time.Hour
+
+	farOut := Record{Host: "a", Up: true, Results: []ResultRecord{
+		{Addr: "1.1.1.1", Port: "443", Open: true, Certs: []CertRecord{{NotAfter: now.Add(30 * 24 * time.Hour)}}},
+	}}
+	soon := Record{Host: "a", Up: true, Results: []ResultRecord{
+		{Addr: "1.1.1.1", Port: "443", Open: true, Certs: []CertRecord{{NotAfter: now.Add(24 * time.Hour)}}},
+	}}
+
+	events := diffRecords(farOut, soon, now, certWarn)
+	if len(events) != 1 || events[0].Type != "cert_expiring" {
+		t.Fatalf("got %+v, want a single \"cert_expiring\" event", events)
+	}
+
+	// A certificate that isn't near expiry must never fire the event, no
+	// matter how many cycles it's compared across.
+	events = diffRecords(farOut, farOut, now, certWarn)
+	if len(events) != 0 {
+		t.Fatalf("steady state, not expiring: got %+v, want no events", events)
+	}
+
+	// An already-expired certificate must fire the event too: this is the
+	// scenario the feature exists to catch, and it only works if the probe
+	// still populates cert data for untrusted/expired certs (see probeTLS).
+	alreadyExpired := Record{Host: "a", Up: true, Results: []ResultRecord{
+		{Addr: "1.1.1.1", Port: "443", Open: true, Certs: []CertRecord{{NotAfter: now.Add(-time.Hour)}}},
+	}}
+	events = diffRecords(farOut, alreadyExpired, now, certWarn)
+	if len(events) != 1 || events[0].Type != "cert_expiring" {
+		t.Fatalf("already-expired cert: got %+v, want a single \"cert_expiring\" event", events)
+	}
+
+	// No repeat event once the warning has already fired.
+	events = diffRecords(soon, soon, now, certWarn)
+	if len(events) != 0 {
+		t.Fatalf("steady state: got %+v, want no events", events)
+	}
+}
+
+func TestSameAddrs(t *testing.T) {
+	if !sameAddrs([]string{"a", "b"}, []string{"b", "a"}) {
+		t.Error("expected reordered slices to be equal")
+	}
+	if sameAddrs([]string{"a"}, []string{"a", "b"}) {
+		t.Error("expected different-length slices to differ")
+	}
+	if sameAddrs([]string{"a"}, []string{"b"}) {
+		t.Error("expected different elements to differ")
+	}
+}